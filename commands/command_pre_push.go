@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/lfs/events"
+	"github.com/github/git-lfs/lfs/transfer/cdc"
 	"github.com/github/git-lfs/vendor/_nuts/github.com/spf13/cobra"
 )
 
@@ -19,6 +22,10 @@ var (
 		Run:   prePushCommand,
 	}
 	prePushDryRun        = false
+	prePushResume        = false
+	prePushFresh         = false
+	prePushPorcelain     = false
+	prePushJSON          = false
 	prePushDeleteBranch  = "(delete)"
 	prePushMissingErrMsg = "%s is an LFS pointer to %s, which does not exist in .git/lfs/objects.\n\nRun 'git lfs fsck' to verify Git LFS objects."
 )
@@ -46,7 +53,7 @@ var (
 // In the case of deleting a branch, no attempts to push Git LFS objects will be
 // made.
 func prePushCommand(cmd *cobra.Command, args []string) {
-	var left, right string
+	var remoteRef, left, right string
 
 	if len(args) == 0 {
 		Print("This should be run through Git's pre-push hook.  Run `git lfs update` to install it.")
@@ -55,6 +62,25 @@ func prePushCommand(cmd *cobra.Command, args []string) {
 
 	lfs.Config.CurrentRemote = args[0]
 
+	var eventBus *events.Bus
+	var writerDone chan struct{}
+	if prePushPorcelain || prePushJSON {
+		eventBus = events.NewBus()
+		lfs.Config.Events = eventBus
+		writerDone = make(chan struct{})
+		go writePorcelainEvents(eventBus, writerDone)
+	}
+	// os.Exit skips deferred calls, so every exit path below that follows a
+	// published event flushes the event bus itself via this helper rather
+	// than relying on defer.
+	flushEvents := func() {
+		if eventBus == nil {
+			return
+		}
+		eventBus.Close()
+		<-writerDone
+	}
+
 	refsData, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		Panic(err, "Error reading refs on stdin")
@@ -64,7 +90,7 @@ func prePushCommand(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	left, right = decodeRefs(string(refsData))
+	remoteRef, left, right = decodePushTuple(string(refsData))
 	if left == prePushDeleteBranch {
 		return
 	}
@@ -88,13 +114,35 @@ func prePushCommand(cmd *cobra.Command, args []string) {
 		var err error
 		skipObjects, err = prePushCheckForMissingObjects(pointers)
 		if err != nil {
+			lfs.Config.Events.Publish(&events.Event{Type: events.TypeError, Message: err.Error()})
+			flushEvents()
 			Panic(errors.New("Missing objects to push"), err.Error())
 		}
+
+		if err := prePushEnforcePolicy(remoteRef, pointers, skipObjects); err != nil {
+			lfs.Config.Events.Publish(&events.Event{Type: events.TypeError, Message: err.Error()})
+			flushEvents()
+			Exit(err.Error())
+		}
 	}
 
+	// --fresh always wins a conflict with --resume; resuming is otherwise
+	// the default so an interrupted push can be re-run unmodified.
+	lfs.Config.UploadFresh = prePushFresh
+	lfs.Config.UploadResume = !prePushFresh
+
+	// Large objects get a shot at the content-defined-chunking adapter
+	// first; the upload queue sends anything under the adapter's size
+	// threshold through its own default upload path.
+	lfs.Config.TransferAdapters = []lfs.TransferAdapter{cdc.NewAdapter()}
+
 	uploadQueue := lfs.NewUploadQueue(len(pointers), totalSize, prePushDryRun)
 
 	for _, pointer := range pointers {
+		lfs.Config.Events.Publish(&events.Event{
+			Type: events.TypeScan, Oid: pointer.Oid, Name: pointer.Name, Size: pointer.Size,
+		})
+
 		if prePushDryRun {
 			Print("push %s", pointer.Name)
 			continue
@@ -102,11 +150,20 @@ func prePushCommand(cmd *cobra.Command, args []string) {
 
 		if _, skip := skipObjects[pointer.Oid]; skip {
 			// object missing locally but on server, don't bother
+			lfs.Config.Events.Publish(&events.Event{
+				Type: events.TypeSkip, Oid: pointer.Oid, Name: pointer.Name,
+				Reason: "already on server",
+			})
 			continue
 		}
 
 		u, wErr := lfs.NewUploadable(pointer.Oid, pointer.Name)
 		if wErr != nil {
+			lfs.Config.Events.Publish(&events.Event{
+				Type: events.TypeError, Oid: pointer.Oid, Name: pointer.Name, Message: wErr.Error(),
+			})
+			flushEvents()
+
 			if cleanPointerErr, ok := wErr.Err.(*lfs.CleanedPointerError); ok {
 				Exit(prePushMissingErrMsg, pointer.Name, cleanPointerErr.Pointer.Oid)
 			} else if Debugging || wErr.Panic {
@@ -130,11 +187,20 @@ func prePushCommand(cmd *cobra.Command, args []string) {
 		}
 
 		if len(uploadQueue.Errors()) > 0 {
+			flushEvents()
 			os.Exit(2)
 		}
 	}
+
+	flushEvents()
 }
 
+// prePushCheckForMissingObjects finds any pointers that are missing from the
+// local LFS object store and asks the server, in a single batch call via
+// lfs.Exists, whether it already has copies of them. Objects the server has
+// are returned so the caller can skip uploading them; if the server doesn't
+// support the batch exist check, this falls back to the slower CheckQueue,
+// which verifies objects one at a time.
 func prePushCheckForMissingObjects(pointers []*lfs.WrappedPointer) (objectsOnServer map[string]struct{}, e error) {
 	var missingLocalObjects []*lfs.WrappedPointer
 	var missingSize int64
@@ -152,6 +218,27 @@ func prePushCheckForMissingObjects(pointers []*lfs.WrappedPointer) (objectsOnSer
 		return nil, nil
 	}
 
+	// Try to resolve everything in one round trip before falling back to
+	// the slower per-object CheckQueue below.
+	onServer, batchOk, err := lfs.Exists(missingLocalObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	if batchOk {
+		var combinedMsg bytes.Buffer
+		for _, p := range missingLocalObjects {
+			if _, ok := onServer[p.Oid]; !ok {
+				combinedMsg.WriteString(fmt.Sprintf(prePushMissingErrMsg, p.Name, p.Oid))
+				combinedMsg.WriteString("\n")
+			}
+		}
+		if combinedMsg.Len() > 0 {
+			return nil, errors.New(combinedMsg.String())
+		}
+		return skipObjects, nil
+	}
+
 	checkQueue := lfs.NewCheckQueue(len(missingLocalObjects), missingSize, false)
 	for _, p := range missingLocalObjects {
 		checkQueue.Add(lfs.NewCheckable(p))
@@ -171,24 +258,141 @@ func prePushCheckForMissingObjects(pointers []*lfs.WrappedPointer) (objectsOnSer
 	return skipObjects, nil
 }
 
+// prePushEnforcePolicy checks the pointers about to be pushed on remoteRef
+// against lfs.PushPolicy's per-ref rules (read from .lfsconfig), rejecting
+// the push with a structured, per-violation report if any rule is broken.
+// A repo with no matching rule for remoteRef is always allowed through.
+func prePushEnforcePolicy(remoteRef string, pointers []*lfs.WrappedPointer, skipObjects map[string]struct{}) error {
+	policy, err := lfs.LoadPushPolicy()
+	if err != nil {
+		return err
+	}
+
+	rule := policy.Match(remoteRef)
+	if rule == nil {
+		return nil
+	}
+
+	locks, err := lfs.ListLocks()
+	if err != nil {
+		return err
+	}
+
+	// skipObjects is already confirmed present on the server (that's why
+	// prePushCheckForMissingObjects decided to skip uploading it).
+	onServer := make(map[string]struct{}, len(pointers))
+	for oid := range skipObjects {
+		onServer[oid] = struct{}{}
+	}
+
+	if rule.RequireServerCopy {
+		// skipObjects only covers objects prePushCheckForMissingObjects
+		// found missing locally. Ask the server directly about everything
+		// else so RequireServerCopy reflects real server-side state
+		// instead of local object-store presence.
+		var toQuery []*lfs.WrappedPointer
+		for _, p := range pointers {
+			if _, known := onServer[p.Oid]; !known {
+				toQuery = append(toQuery, p)
+			}
+		}
+
+		if len(toQuery) > 0 {
+			found, batchOk, err := lfs.Exists(toQuery)
+			if err != nil {
+				return err
+			}
+
+			if batchOk {
+				for oid := range found {
+					onServer[oid] = struct{}{}
+				}
+			} else {
+				// The batch "exist" op isn't supported here either; fall
+				// back to the same per-object CheckQueue
+				// prePushCheckForMissingObjects uses rather than treating
+				// "couldn't verify" as "not present on the server".
+				var toQuerySize int64
+				for _, p := range toQuery {
+					toQuerySize += p.Size
+				}
+
+				checkQueue := lfs.NewCheckQueue(len(toQuery), toQuerySize, false)
+				for _, p := range toQuery {
+					checkQueue.Add(lfs.NewCheckable(p))
+				}
+				checkQueue.Wait()
+
+				notOnServer := make(map[string]struct{}, len(checkQueue.Errors()))
+				for _, wrerr := range checkQueue.Errors() {
+					notOnServer[wrerr.Get("oid")] = struct{}{}
+				}
+				for _, p := range toQuery {
+					if _, missing := notOnServer[p.Oid]; !missing {
+						onServer[p.Oid] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+
+	violations := policy.Evaluate(remoteRef, pointers, locks, onServer)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &lfs.PolicyError{Violations: violations}
+}
+
+// writePorcelainEvents drains bus, writing one JSON object per line to
+// stdout for every scan decision, skip decision, upload progress event, and
+// terminal error the pre-push hook publishes. Progress events that bus drops
+// because this subscriber fell behind are not written. It's how --porcelain /
+// --json let GUI clients and CI wrappers drive the hook without scraping
+// human-formatted Print/Error output. done is closed once bus is closed and
+// every pending event has been written.
+func writePorcelainEvents(bus *events.Bus, done chan struct{}) {
+	defer close(done)
+
+	enc := json.NewEncoder(os.Stdout)
+	for e := range bus.Subscribe() {
+		enc.Encode(e)
+	}
+}
+
 // decodeRefs pulls the sha1s out of the line read from the pre-push
 // hook's stdin.
 func decodeRefs(input string) (string, string) {
+	_, left, right := decodePushTuple(input)
+	return left, right
+}
+
+// decodePushTuple parses the full "<local ref> <local sha1> <remote ref>
+// <remote sha1>" tuple Git passes on stdin, additionally returning the
+// remote ref name so callers can apply per-ref policy.
+func decodePushTuple(input string) (remoteRef, left, right string) {
 	refs := strings.Split(strings.TrimSpace(input), " ")
-	var left, right string
 
 	if len(refs) > 1 {
 		left = refs[1]
 	}
 
+	if len(refs) > 2 {
+		remoteRef = refs[2]
+	}
+
 	if len(refs) > 3 {
 		right = "^" + refs[3]
 	}
 
-	return left, right
+	return remoteRef, left, right
 }
 
 func init() {
 	prePushCmd.Flags().BoolVarP(&prePushDryRun, "dry-run", "d", false, "Do everything except actually send the updates")
+	prePushCmd.Flags().BoolVar(&prePushResume, "resume", false, "Resume from a checkpoint left by a previously interrupted push (default behavior)")
+	prePushCmd.Flags().BoolVar(&prePushFresh, "fresh", false, "Ignore any checkpoint from a previously interrupted push and start over")
+	prePushCmd.Flags().BoolVar(&prePushPorcelain, "porcelain", false, "Emit one JSON event per line on stdout, alongside the usual human-readable output")
+	prePushCmd.Flags().BoolVar(&prePushJSON, "json", false, "Alias for --porcelain")
 	RootCmd.AddCommand(prePushCmd)
 }
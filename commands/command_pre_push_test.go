@@ -0,0 +1,46 @@
+package commands
+
+import "testing"
+
+func TestDecodePushTuple(t *testing.T) {
+	cases := []struct {
+		input     string
+		remoteRef string
+		left      string
+		right     string
+	}{
+		{
+			input:     "refs/heads/main abc123 refs/heads/main def456\n",
+			remoteRef: "refs/heads/main",
+			left:      "abc123",
+			right:     "^def456",
+		},
+		{
+			input:     "refs/heads/main " + prePushDeleteBranch + " refs/heads/main def456\n",
+			remoteRef: "refs/heads/main",
+			left:      prePushDeleteBranch,
+			right:     "^def456",
+		},
+		{
+			input:     "",
+			remoteRef: "",
+			left:      "",
+			right:     "",
+		},
+	}
+
+	for _, c := range cases {
+		remoteRef, left, right := decodePushTuple(c.input)
+		if remoteRef != c.remoteRef || left != c.left || right != c.right {
+			t.Errorf("decodePushTuple(%q) = (%q, %q, %q), want (%q, %q, %q)",
+				c.input, remoteRef, left, right, c.remoteRef, c.left, c.right)
+		}
+	}
+}
+
+func TestDecodeRefs(t *testing.T) {
+	left, right := decodeRefs("refs/heads/main abc123 refs/heads/main def456\n")
+	if left != "abc123" || right != "^def456" {
+		t.Errorf("decodeRefs = (%q, %q), want (%q, %q)", left, right, "abc123", "^def456")
+	}
+}
@@ -0,0 +1,123 @@
+package lfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// existRequest is the body sent to the LFS batch API to ask the server
+// which of a set of objects it already has, without requesting upload or
+// download URLs for them.
+type existRequest struct {
+	Operation string              `json:"operation"`
+	Objects   []*existRequestItem `json:"objects"`
+}
+
+type existRequestItem struct {
+	Oid  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type existResponse struct {
+	Objects []*existResponseItem `json:"objects"`
+}
+
+type existResponseItem struct {
+	Oid   string `json:"oid"`
+	Size  int64  `json:"size"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// BatchExistSupported is cached for the lifetime of the process once the
+// server has told us whether it supports the "exist" batch operation. A
+// server that doesn't recognize it answers with 404/501, or rejects the
+// unknown operation with some other 4xx, at which point we fall back to
+// checking objects one at a time.
+var BatchExistSupported = true
+
+// batchOperationUnsupported reports whether code indicates the server
+// didn't understand the batch "exist" operation, rather than a genuine
+// error processing it. Servers vary in how they reject an operation they
+// don't recognize (404, 501, or a generic 400/422), so any 4xx is treated
+// the same as 404/501 here.
+func batchOperationUnsupported(code int) bool {
+	return code == http.StatusNotImplemented || (code >= 400 && code < 500)
+}
+
+// Exists asks the server, in a single batch call, which of the given
+// pointers it already has a copy of. It returns the set of OIDs the server
+// reports having, and a bool indicating whether the server understood the
+// batch "exist" operation at all. Callers should fall back to per-object
+// checks (e.g. NewCheckQueue) when ok is false.
+func Exists(pointers []*WrappedPointer) (oids map[string]struct{}, ok bool, err error) {
+	if !BatchExistSupported || len(pointers) == 0 {
+		return nil, BatchExistSupported, nil
+	}
+
+	items := make([]*existRequestItem, 0, len(pointers))
+	for _, p := range pointers {
+		items = append(items, &existRequestItem{Oid: p.Oid, Size: p.Size})
+	}
+
+	body, err := json.Marshal(&existRequest{Operation: "exist", Objects: items})
+	if err != nil {
+		return nil, true, err
+	}
+
+	req, err := NewBatchApiRequest("POST")
+	if err != nil {
+		return nil, true, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.git-lfs+json")
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	req.Body = NewByteBody(body)
+
+	res, objs, err := doApiBatchRequest(req)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if batchOperationUnsupported(res.StatusCode) {
+		// Server doesn't know about the "exist" operation; remember that
+		// for the rest of this process so we don't keep paying the round
+		// trip on every push.
+		BatchExistSupported = false
+		return nil, false, nil
+	}
+
+	found := make(map[string]struct{}, len(objs))
+	for _, o := range objs {
+		if o.Error == nil {
+			found[o.Oid] = struct{}{}
+		}
+	}
+
+	return found, true, nil
+}
+
+func doApiBatchRequest(req *http.Request) (*http.Response, []*existResponseItem, error) {
+	res, err := DoHTTP(Config, req)
+	if err != nil {
+		return res, nil, Error(err)
+	}
+	defer res.Body.Close()
+
+	if batchOperationUnsupported(res.StatusCode) {
+		return res, nil, nil
+	}
+
+	if res.StatusCode > 299 {
+		return res, nil, Errorf(nil, "Invalid server response: %d", res.StatusCode)
+	}
+
+	var eres existResponse
+	if err := json.NewDecoder(res.Body).Decode(&eres); err != nil {
+		return res, nil, Error(err)
+	}
+
+	return res, eres.Objects, nil
+}
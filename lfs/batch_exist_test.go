@@ -0,0 +1,68 @@
+package lfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestBatchOperationUnsupported(t *testing.T) {
+	cases := []struct {
+		code int
+		want bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusCreated, false},
+		{http.StatusNotFound, true},
+		{http.StatusNotImplemented, true},
+		{http.StatusBadRequest, true},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+	}
+
+	for _, c := range cases {
+		if got := batchOperationUnsupported(c.code); got != c.want {
+			t.Errorf("batchOperationUnsupported(%d) = %v, want %v", c.code, got, c.want)
+		}
+	}
+}
+
+func TestExistRequestResponseJSON(t *testing.T) {
+	req := &existRequest{
+		Operation: "exist",
+		Objects:   []*existRequestItem{{Oid: "abc123", Size: 42}},
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded existRequest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if decoded.Operation != "exist" || len(decoded.Objects) != 1 {
+		t.Fatalf("unexpected round-trip: %+v", decoded)
+	}
+	if decoded.Objects[0].Oid != "abc123" || decoded.Objects[0].Size != 42 {
+		t.Fatalf("unexpected object: %+v", decoded.Objects[0])
+	}
+
+	res := []byte(`{"objects":[{"oid":"abc123","size":42},{"oid":"missing","size":1,"error":{"code":404,"message":"not found"}}]}`)
+	var eres existResponse
+	if err := json.Unmarshal(res, &eres); err != nil {
+		t.Fatalf("Unmarshal response: %v", err)
+	}
+	if len(eres.Objects) != 2 {
+		t.Fatalf("expected 2 objects, got %d", len(eres.Objects))
+	}
+	if eres.Objects[0].Error != nil {
+		t.Errorf("expected first object to have no error, got %+v", eres.Objects[0].Error)
+	}
+	if eres.Objects[1].Error == nil || eres.Objects[1].Error.Code != 404 {
+		t.Errorf("expected second object to have a 404 error, got %+v", eres.Objects[1].Error)
+	}
+}
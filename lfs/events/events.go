@@ -0,0 +1,114 @@
+// Package events defines the versioned, JSON-serializable event schema
+// emitted by commands like `git lfs pre-push` when run with --json, and a
+// small pub/sub Bus for fanning those events out to whatever is rendering
+// them (a terminal formatter, a GUI client, a CI wrapper).
+package events
+
+import "sync"
+
+// SchemaVersion is bumped whenever a field is added, removed, or changes
+// meaning, so consumers can detect incompatible changes instead of
+// silently misparsing a newer client's output.
+const SchemaVersion = 1
+
+// Type identifies what kind of thing happened.
+type Type string
+
+const (
+	// TypeScan is published once per pointer found while scanning the
+	// refs being pushed, before any upload decision has been made.
+	TypeScan Type = "scan"
+	// TypeSkip is published when a pointer is not going to be uploaded,
+	// e.g. because the server already has a copy.
+	TypeSkip Type = "skip"
+	// TypeProgress is published as bytes of a single object are sent.
+	TypeProgress Type = "progress"
+	// TypeComplete is published once an object finishes uploading.
+	TypeComplete Type = "complete"
+	// TypeError is published for a terminal, per-object or whole-push
+	// error.
+	TypeError Type = "error"
+)
+
+// Event is one line of --json output. Fields that don't apply to a given
+// Type are omitted.
+type Event struct {
+	Schema  int    `json:"schema"`
+	Type    Type   `json:"type"`
+	Oid     string `json:"oid,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Bus fans published events out to every subscriber. The zero value is an
+// empty Bus, the same as NewBus returns. A nil *Bus is safe to Publish to
+// (it's a no-op), so
+// callers that don't have --json enabled don't need to guard every call
+// with a nil check.
+type Bus struct {
+	mu   sync.Mutex
+	subs []chan *Event
+}
+
+// NewBus creates an empty Bus ready to accept subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe returns a channel that receives every event published from this
+// point on. The channel is closed when the Bus is closed.
+func (b *Bus) Subscribe() <-chan *Event {
+	ch := make(chan *Event, 100)
+	b.mu.Lock()
+	b.subs = append(b.subs, ch)
+	b.mu.Unlock()
+	return ch
+}
+
+// Publish stamps e with the current schema version and fans it out to every
+// subscriber. Publishing on a nil Bus is a no-op.
+//
+// Scan, skip, complete, and error events are always delivered: each reports
+// a one-time decision a consumer needs to see, so a subscriber that falls
+// behind must not cause one to be silently lost. Progress events are the
+// exception — they're frequent, per-byte updates where a later event
+// supersedes an earlier one, so a slow subscriber drops them rather than
+// stalling the upload that's producing them.
+func (b *Bus) Publish(e *Event) {
+	if b == nil {
+		return
+	}
+
+	e.Schema = SchemaVersion
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs {
+		if e.Type == TypeProgress {
+			select {
+			case ch <- e:
+			default:
+			}
+			continue
+		}
+
+		ch <- e
+	}
+}
+
+// Close shuts down every subscriber channel. Closing a nil Bus is a no-op.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs {
+		close(ch)
+	}
+	b.subs = nil
+}
@@ -0,0 +1,101 @@
+package events
+
+import "testing"
+
+func TestPublishNilBusIsNoop(t *testing.T) {
+	var bus *Bus
+	bus.Publish(&Event{Type: TypeScan}) // must not panic
+}
+
+func TestPublishStampsSchemaVersion(t *testing.T) {
+	bus := NewBus()
+	ch := bus.Subscribe()
+
+	bus.Publish(&Event{Type: TypeScan, Oid: "abc"})
+
+	e := <-ch
+	if e.Schema != SchemaVersion {
+		t.Errorf("Schema = %d, want %d", e.Schema, SchemaVersion)
+	}
+	if e.Oid != "abc" {
+		t.Errorf("Oid = %q, want %q", e.Oid, "abc")
+	}
+}
+
+func TestPublishDropsProgressEventsWhenSubscriberIsFull(t *testing.T) {
+	bus := NewBus()
+	ch := bus.Subscribe()
+
+	// Fill the subscriber's buffer without draining it.
+	for i := 0; i < cap(ch)+5; i++ {
+		bus.Publish(&Event{Type: TypeProgress, Bytes: int64(i)})
+	}
+
+	if len(ch) != cap(ch) {
+		t.Fatalf("expected channel to be full at %d, got %d", cap(ch), len(ch))
+	}
+}
+
+func TestPublishNeverDropsErrorEvents(t *testing.T) {
+	bus := NewBus()
+	ch := bus.Subscribe()
+
+	// Fill the subscriber's buffer with progress events, which are
+	// droppable, then publish an error from another goroutine so the
+	// (potentially blocking) send doesn't deadlock the test.
+	for i := 0; i < cap(ch); i++ {
+		bus.Publish(&Event{Type: TypeProgress})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		bus.Publish(&Event{Type: TypeError, Message: "boom"})
+		close(done)
+	}()
+
+	// Drain enough room for the error event to land, then confirm it
+	// wasn't dropped despite the full buffer.
+	var sawError bool
+	for i := 0; i < cap(ch); i++ {
+		<-ch
+	}
+	e := <-ch
+	if e.Type == TypeError {
+		sawError = true
+	}
+	<-done
+
+	if !sawError {
+		t.Fatalf("expected the error event to survive a full buffer, got %+v", e)
+	}
+}
+
+func TestPublishNeverDropsScanOrSkipEvents(t *testing.T) {
+	bus := NewBus()
+	ch := bus.Subscribe()
+
+	// Fill the subscriber's buffer with progress events, which are
+	// droppable, then publish scan and skip from another goroutine so the
+	// (potentially blocking) sends don't deadlock the test.
+	for i := 0; i < cap(ch); i++ {
+		bus.Publish(&Event{Type: TypeProgress})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		bus.Publish(&Event{Type: TypeScan, Oid: "scan-oid"})
+		bus.Publish(&Event{Type: TypeSkip, Oid: "skip-oid"})
+		close(done)
+	}()
+
+	var seen []Type
+	for i := 0; i < cap(ch); i++ {
+		<-ch
+	}
+	seen = append(seen, (<-ch).Type, (<-ch).Type)
+	<-done
+
+	if len(seen) != 2 || seen[0] != TypeScan || seen[1] != TypeSkip {
+		t.Fatalf("expected scan then skip to survive a full buffer, got %+v", seen)
+	}
+}
@@ -0,0 +1,61 @@
+package lfs
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Lock describes a single file lock held on the LFS server, as reported by
+// the locking API.
+type Lock struct {
+	Id       string `json:"id"`
+	Path     string `json:"path"`
+	Owner    string `json:"owner"`
+	LockedAt string `json:"locked_at"`
+}
+
+type locksResponse struct {
+	Locks []Lock `json:"locks"`
+}
+
+// ListLocks fetches every lock currently held on the server for the current
+// remote. It is used by the push policy evaluator to reject pushes that
+// touch files locked by someone else.
+func ListLocks() ([]Lock, error) {
+	req, err := NewApiRequest("GET", "locks")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := DoHTTP(Config, req)
+	if err != nil {
+		return nil, Error(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		// Server doesn't support locking; treat as "no locks".
+		return nil, nil
+	}
+
+	if res.StatusCode > 299 {
+		return nil, Errorf(nil, "Invalid server response: %d", res.StatusCode)
+	}
+
+	var lres locksResponse
+	if err := json.NewDecoder(res.Body).Decode(&lres); err != nil {
+		return nil, Error(err)
+	}
+
+	return lres.Locks, nil
+}
+
+// OwnedByOther reports whether the lock was taken out by someone other than
+// the current user, as identified by Config.CurrentUser().
+func (l Lock) OwnedByOther() bool {
+	return l.Owner != "" && l.Owner != Config.CurrentUser()
+}
+
+func (l Lock) String() string {
+	return fmt.Sprintf("%s (locked by %s)", l.Path, l.Owner)
+}
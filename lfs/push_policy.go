@@ -0,0 +1,151 @@
+package lfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RefPolicy is the set of push rules that apply to refs matching Pattern, as
+// read from the `lfs.<pattern>.*` sections of .lfsconfig. Pattern is matched
+// against the destination ref using the same globbing rules as .gitattributes
+// path patterns (e.g. "refs/heads/release/*").
+type RefPolicy struct {
+	Pattern            string
+	ForbidLockedFiles  bool
+	RequireServerCopy  bool
+	ForbidPointerTypes []string
+}
+
+// PushPolicy is the parsed collection of per-ref rules that prePushCommand
+// checks before enqueueing any uploads.
+type PushPolicy struct {
+	Refs []*RefPolicy
+}
+
+// Violation describes a single pointer that failed a push policy check.
+type Violation struct {
+	Ref     string
+	Pointer *WrappedPointer
+	Reason  string
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("%s: %s (%s)", v.Ref, v.Pointer.Name, v.Reason)
+}
+
+// PolicyError collects every Violation found while evaluating a push so the
+// caller can report them all at once instead of aborting on the first.
+type PolicyError struct {
+	Violations []*Violation
+}
+
+func (e *PolicyError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("push rejected by lfs.PushPolicy:\n")
+	for _, v := range e.Violations {
+		sb.WriteString("  ")
+		sb.WriteString(v.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// LoadPushPolicy reads per-ref push rules out of .lfsconfig. A repo with no
+// such rules gets back an empty, always-passing policy.
+//
+// Example .lfsconfig:
+//
+//   [lfs "refs/heads/release/*"]
+//     forbidlockedfiles = true
+//     requireservercopy = true
+//     forbidpointertypes = git-lfs-rsa
+func LoadPushPolicy() (*PushPolicy, error) {
+	policy := &PushPolicy{}
+
+	sections, err := Config.LfsConfigSections("lfs")
+	if err != nil {
+		return nil, err
+	}
+
+	for pattern, values := range sections {
+		if !strings.HasPrefix(pattern, "refs/") {
+			// Not a ref rule (e.g. could be an endpoint URL section).
+			continue
+		}
+
+		rp := &RefPolicy{Pattern: pattern}
+		rp.ForbidLockedFiles = values["forbidlockedfiles"] == "true"
+		rp.RequireServerCopy = values["requireservercopy"] == "true"
+		if types := values["forbidpointertypes"]; len(types) > 0 {
+			rp.ForbidPointerTypes = strings.Split(types, ",")
+		}
+
+		policy.Refs = append(policy.Refs, rp)
+	}
+
+	return policy, nil
+}
+
+// Match returns the RefPolicy whose pattern matches ref, or nil if no rule
+// applies to it.
+func (p *PushPolicy) Match(ref string) *RefPolicy {
+	for _, rp := range p.Refs {
+		if ok, _ := filepath.Match(rp.Pattern, ref); ok {
+			return rp
+		}
+	}
+	return nil
+}
+
+// Evaluate checks every pointer about to be pushed on ref against the
+// matching RefPolicy (if any), using locks and onServer (the set of OIDs the
+// server already has, as returned by Exists) to decide violations. It
+// returns every violation found rather than stopping at the first.
+func (p *PushPolicy) Evaluate(ref string, pointers []*WrappedPointer, locks []Lock, onServer map[string]struct{}) []*Violation {
+	rule := p.Match(ref)
+	if rule == nil {
+		return nil
+	}
+
+	lockedPaths := make(map[string]Lock, len(locks))
+	for _, l := range locks {
+		if l.OwnedByOther() {
+			lockedPaths[l.Path] = l
+		}
+	}
+
+	var violations []*Violation
+	for _, pointer := range pointers {
+		if rule.ForbidLockedFiles {
+			if l, locked := lockedPaths[pointer.Name]; locked {
+				violations = append(violations, &Violation{
+					Ref: ref, Pointer: pointer,
+					Reason: fmt.Sprintf("file is locked by %s", l.Owner),
+				})
+			}
+		}
+
+		if rule.RequireServerCopy {
+			if _, ok := onServer[pointer.Oid]; !ok {
+				violations = append(violations, &Violation{
+					Ref: ref, Pointer: pointer,
+					Reason: "protected ref requires all pointers to already exist on the server",
+				})
+			}
+		}
+
+		if len(rule.ForbidPointerTypes) > 0 {
+			for _, t := range rule.ForbidPointerTypes {
+				if pointer.PointerType() == t {
+					violations = append(violations, &Violation{
+						Ref: ref, Pointer: pointer,
+						Reason: fmt.Sprintf("pointer type %q is not allowed on this ref", t),
+					})
+				}
+			}
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,86 @@
+package lfs
+
+import "testing"
+
+func TestPushPolicyMatch(t *testing.T) {
+	policy := &PushPolicy{Refs: []*RefPolicy{
+		{Pattern: "refs/heads/release/*"},
+		{Pattern: "refs/heads/main"},
+	}}
+
+	cases := []struct {
+		ref  string
+		want string
+	}{
+		{"refs/heads/release/1.0", "refs/heads/release/*"},
+		{"refs/heads/release/2.1", "refs/heads/release/*"},
+		{"refs/heads/main", "refs/heads/main"},
+		{"refs/heads/feature/x", ""},
+	}
+
+	for _, c := range cases {
+		rule := policy.Match(c.ref)
+		got := ""
+		if rule != nil {
+			got = rule.Pattern
+		}
+		if got != c.want {
+			t.Errorf("Match(%q) matched %q, want %q", c.ref, got, c.want)
+		}
+	}
+}
+
+func TestPushPolicyEvaluateForbidLockedFiles(t *testing.T) {
+	policy := &PushPolicy{Refs: []*RefPolicy{
+		{Pattern: "refs/heads/release/*", ForbidLockedFiles: true},
+	}}
+
+	pointers := []*WrappedPointer{
+		{Pointer: &Pointer{Oid: "a", Size: 1}, Name: "locked.bin"},
+		{Pointer: &Pointer{Oid: "b", Size: 1}, Name: "free.bin"},
+	}
+	locks := []Lock{
+		{Path: "locked.bin", Owner: "someone-else"},
+	}
+
+	violations := policy.Evaluate("refs/heads/release/1.0", pointers, locks, nil)
+	if len(violations) != 1 {
+		t.Fatalf("expected 1 violation, got %d: %+v", len(violations), violations)
+	}
+	if violations[0].Pointer.Name != "locked.bin" {
+		t.Errorf("expected violation for locked.bin, got %s", violations[0].Pointer.Name)
+	}
+}
+
+func TestPushPolicyEvaluateRequireServerCopy(t *testing.T) {
+	policy := &PushPolicy{Refs: []*RefPolicy{
+		{Pattern: "refs/heads/release/*", RequireServerCopy: true},
+	}}
+
+	pointers := []*WrappedPointer{
+		{Pointer: &Pointer{Oid: "a", Size: 1}, Name: "on-server.bin"},
+		{Pointer: &Pointer{Oid: "b", Size: 1}, Name: "not-on-server.bin"},
+	}
+	onServer := map[string]struct{}{"a": {}}
+
+	violations := policy.Evaluate("refs/heads/release/1.0", pointers, nil, onServer)
+	if len(violations) != 1 {
+		t.Fatalf("expected 1 violation, got %d: %+v", len(violations), violations)
+	}
+	if violations[0].Pointer.Name != "not-on-server.bin" {
+		t.Errorf("expected violation for not-on-server.bin, got %s", violations[0].Pointer.Name)
+	}
+}
+
+func TestPushPolicyEvaluateNoMatchingRule(t *testing.T) {
+	policy := &PushPolicy{Refs: []*RefPolicy{
+		{Pattern: "refs/heads/release/*", ForbidLockedFiles: true},
+	}}
+
+	pointers := []*WrappedPointer{{Pointer: &Pointer{Oid: "a", Size: 1}, Name: "anything.bin"}}
+	locks := []Lock{{Path: "anything.bin", Owner: "someone-else"}}
+
+	if violations := policy.Evaluate("refs/heads/main", pointers, locks, nil); len(violations) != 0 {
+		t.Fatalf("expected no violations for an unmatched ref, got %+v", violations)
+	}
+}
@@ -0,0 +1,46 @@
+package lfs
+
+import (
+	"io"
+	"time"
+)
+
+// rateLimitedReader throttles reads from an underlying io.Reader to a
+// configured number of bytes per second. It's a simple token-bucket: each
+// Read is capped at bytesPerSec, with the remainder of the second slept off
+// if the caller asks for more than that in a single second-long window.
+type rateLimitedReader struct {
+	r           io.Reader
+	bytesPerSec int64
+
+	windowStart time.Time
+	windowUsed  int64
+}
+
+func newRateLimitedReader(r io.Reader, bytesPerSec int64) *rateLimitedReader {
+	return &rateLimitedReader{r: r, bytesPerSec: bytesPerSec}
+}
+
+func (rl *rateLimitedReader) Read(p []byte) (int, error) {
+	now := time.Now()
+	if rl.windowStart.IsZero() || now.Sub(rl.windowStart) >= time.Second {
+		rl.windowStart = now
+		rl.windowUsed = 0
+	}
+
+	remaining := rl.bytesPerSec - rl.windowUsed
+	if remaining <= 0 {
+		time.Sleep(time.Second - now.Sub(rl.windowStart))
+		rl.windowStart = time.Now()
+		rl.windowUsed = 0
+		remaining = rl.bytesPerSec
+	}
+
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := rl.r.Read(p)
+	rl.windowUsed += int64(n)
+	return n, err
+}
@@ -0,0 +1,48 @@
+package lfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRateLimitedReaderPreservesData(t *testing.T) {
+	want := bytes.Repeat([]byte("lfs-data-"), 1000)
+
+	rl := newRateLimitedReader(bytes.NewReader(want), 4096)
+
+	got, err := ioutil.ReadAll(rl)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("rate-limited read returned %d bytes, want %d, and/or corrupted content", len(got), len(want))
+	}
+}
+
+func TestRateLimitedReaderCapsSingleRead(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 10000)
+	rl := newRateLimitedReader(bytes.NewReader(data), 100)
+
+	buf := make([]byte, 10000)
+	n, err := rl.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if int64(n) > rl.bytesPerSec {
+		t.Errorf("single Read returned %d bytes, exceeding the %d bytes/sec cap", n, rl.bytesPerSec)
+	}
+}
+
+func TestUploadQueueRateLimitedPassesThroughWithNoCap(t *testing.T) {
+	q := &UploadQueue{bandwidthCap: 0}
+	data := bytes.Repeat([]byte("y"), 10000)
+	src := bytes.NewReader(data)
+
+	r := q.rateLimited(src)
+	if r != src {
+		t.Fatalf("expected rateLimited to return the reader unchanged when bandwidthCap is 0")
+	}
+}
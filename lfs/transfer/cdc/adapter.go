@@ -0,0 +1,230 @@
+package cdc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/github/git-lfs/lfs"
+)
+
+// Threshold is the object size above which the Adapter takes over the
+// upload instead of the queue's default whole/multipart path. Below this,
+// chunking overhead isn't worth it.
+const Threshold = MaxSize
+
+// haveRequest/haveResponse mirror lfs.existRequest/existResponse but probe
+// for individual chunk oids rather than whole objects, so the adapter can
+// skip re-sending chunks the server already has from a previous, near-
+// duplicate upload.
+type haveRequest struct {
+	Operation string   `json:"operation"`
+	Oids      []string `json:"oids"`
+}
+
+type haveResponse struct {
+	Have []string `json:"have"`
+}
+
+// Adapter implements lfs.TransferAdapter using content-defined chunking: it
+// splits the object, asks the server which chunks it's missing, uploads
+// only those, and stores a Manifest pointer in place of the whole blob.
+type Adapter struct{}
+
+// NewAdapter constructs a cdc Adapter ready to register with
+// lfs.Config.TransferAdapters.
+func NewAdapter() *Adapter {
+	return &Adapter{}
+}
+
+// Applicable reports whether u is large enough to be worth chunking.
+func (a *Adapter) Applicable(u *lfs.Uploadable) bool {
+	return u.Size >= Threshold
+}
+
+// Upload splits u into content-defined chunks, asks the server which ones
+// it's missing via a single batch "have" query, uploads only those chunks,
+// and finally uploads the resulting Manifest in place of the whole object.
+// It never holds the whole object in memory, only one chunk's bytes plus
+// the Chunker's read-ahead buffer (up to MaxSize): the object is streamed
+// once to build the Manifest (hashing each chunk and discarding its bytes
+// immediately), then re-read one missing chunk at a time via u.ReaderAt for
+// the actual upload.
+func (a *Adapter) Upload(u *lfs.Uploadable) *lfs.WrappedError {
+	manifest, err := buildManifestStreaming(u)
+	if err != nil {
+		return lfs.Error(err)
+	}
+
+	missing, supported, wErr := haveMissingChunks(manifest)
+	if wErr != nil {
+		return wErr
+	}
+
+	if !supported {
+		// Servers that don't understand the "have" operation have no
+		// endpoint to receive individual chunks either, so fall back to
+		// sending u as a single, unchunked object.
+		return uploadWhole(u)
+	}
+
+	for _, ref := range manifest.Chunks {
+		if _, needed := missing[ref.Oid]; !needed {
+			continue
+		}
+
+		part, err := u.ReaderAt(ref.Offset, ref.Size)
+		if err != nil {
+			return lfs.Error(err)
+		}
+		wErr := uploadChunk(ref, part)
+		part.Close()
+		if wErr != nil {
+			return wErr
+		}
+	}
+
+	body, err := manifest.Marshal()
+	if err != nil {
+		return lfs.Error(err)
+	}
+
+	return u.UploadManifest(body)
+}
+
+// buildManifestStreaming reads u one chunk at a time, hashing each chunk as
+// it's produced and discarding its bytes before the next one is read, so
+// building the manifest never requires holding the whole object in memory.
+func buildManifestStreaming(u *lfs.Uploadable) (*Manifest, error) {
+	reader, err := u.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	manifest := &Manifest{
+		Schema:    ManifestSchemaVersion,
+		WholeOid:  u.Oid,
+		WholeSize: u.Size,
+	}
+
+	chunker := NewChunker(reader)
+	for {
+		chunk, err := chunker.Next()
+		if len(chunk.Data) > 0 {
+			manifest.Chunks = append(manifest.Chunks, ChunkRefFor(chunk))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return manifest, nil
+}
+
+// haveMissingChunks asks the server, in one round trip, which of the
+// manifest's chunk oids it doesn't already have stored. The returned bool
+// reports whether the server understood the "have" operation at all; when
+// it doesn't, the caller must fall back to a whole-object upload since
+// there's no chunk-receiving endpoint to use instead.
+func haveMissingChunks(manifest *Manifest) (oids map[string]struct{}, supported bool, e *lfs.WrappedError) {
+	allOids := make([]string, len(manifest.Chunks))
+	for i, c := range manifest.Chunks {
+		allOids[i] = c.Oid
+	}
+
+	body, err := json.Marshal(&haveRequest{Operation: "have", Oids: allOids})
+	if err != nil {
+		return nil, true, lfs.Error(err)
+	}
+
+	req, err := lfs.NewApiRequest("POST", "chunks/have")
+	if err != nil {
+		return nil, true, lfs.Error(err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.git-lfs+json")
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+
+	res, hres, err := doHaveRequest(req, body)
+	if err != nil {
+		return nil, true, lfs.Error(err)
+	}
+
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusNotImplemented {
+		return nil, false, nil
+	}
+
+	have := make(map[string]struct{}, len(hres.Have))
+	for _, oid := range hres.Have {
+		have[oid] = struct{}{}
+	}
+
+	missing := make(map[string]struct{}, len(allOids))
+	for _, oid := range allOids {
+		if _, ok := have[oid]; !ok {
+			missing[oid] = struct{}{}
+		}
+	}
+	return missing, true, nil
+}
+
+// uploadWhole falls back to sending u as a single, unchunked object, for
+// servers that don't support chunked transfer at all.
+func uploadWhole(u *lfs.Uploadable) *lfs.WrappedError {
+	reader, err := u.Reader()
+	if err != nil {
+		return lfs.Error(err)
+	}
+	defer reader.Close()
+
+	return u.Upload(reader)
+}
+
+func doHaveRequest(req *http.Request, body []byte) (*http.Response, *haveResponse, error) {
+	req.Body = lfs.NewByteBody(body)
+
+	res, err := lfs.DoHTTP(lfs.Config, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusNotImplemented {
+		return res, nil, nil
+	}
+
+	var hres haveResponse
+	if err := json.NewDecoder(res.Body).Decode(&hres); err != nil {
+		return res, nil, err
+	}
+	return res, &hres, nil
+}
+
+// uploadChunk streams a single chunk's bytes to the server, keyed by its
+// content oid, so that a later manifest referencing the same oid (because
+// the source data repeated) can skip uploading it again entirely. data is
+// not closed by uploadChunk; the caller owns its lifetime.
+func uploadChunk(ref ChunkRef, data io.Reader) *lfs.WrappedError {
+	req, err := lfs.NewApiRequest("PUT", "chunks/"+ref.Oid)
+	if err != nil {
+		return lfs.Error(err)
+	}
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", ref.Size))
+	req.Body = ioutil.NopCloser(data)
+
+	res, err := lfs.DoHTTP(lfs.Config, req)
+	if err != nil {
+		return lfs.Error(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode > 299 {
+		return lfs.Errorf(nil, "Error uploading chunk %s: %d", ref.Oid, res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package cdc implements a content-defined-chunking transfer adapter for
+// large LFS objects. Instead of re-uploading an entire blob every time it
+// changes slightly, the object is split into variable-length chunks along
+// content-derived boundaries (so an insertion or deletion in the middle of
+// the file only shifts the chunks around it, not every chunk after it), and
+// only chunks the server doesn't already have are sent.
+package cdc
+
+import (
+	"bufio"
+	"io"
+)
+
+// Chunk boundaries follow the FastCDC scheme: a 64-bit "gear hash" is rolled
+// over a sliding window of the input, and a boundary is cut once the chunk
+// has grown past MinSize and the low bits of the hash match a mask sized so
+// that the expected chunk size is AvgSize.
+const (
+	MinSize = 2 * 1024 * 1024  // 2 MiB
+	AvgSize = 8 * 1024 * 1024  // 8 MiB
+	MaxSize = 32 * 1024 * 1024 // 32 MiB
+)
+
+// maskBits is chosen so that 1/2^maskBits == 1/AvgSize, i.e. a boundary is
+// expected, on average, every AvgSize bytes.
+var maskBits = bitLength(AvgSize)
+
+// boundaryMask is ANDed with the rolling hash; a chunk boundary is cut when
+// the result is zero.
+var boundaryMask = uint64(1)<<uint(maskBits) - 1
+
+func bitLength(n int) uint {
+	var bits uint
+	for n > 1 {
+		n >>= 1
+		bits++
+	}
+	return bits
+}
+
+// gearTable holds 256 pseudo-random 64-bit constants, one per possible
+// input byte, used to roll the gear hash forward one byte at a time. It's
+// generated once at init with a fixed seed so every client derives
+// identical chunk boundaries for the same bytes.
+var gearTable = generateGearTable()
+
+func generateGearTable() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x9e3779b97f4a7c15) // splitmix64 seed
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}
+
+// Chunk is one content-defined slice of the original object.
+type Chunk struct {
+	Offset int64
+	Data   []byte
+}
+
+// Chunker cuts chunk boundaries from a reader one at a time, so a caller
+// that only needs to hash-and-discard each chunk (rather than hold the
+// whole object in memory) can do so with Next.
+type Chunker struct {
+	br     *bufio.Reader
+	offset int64
+}
+
+// NewChunker wraps r so its FastCDC chunk boundaries can be read one at a
+// time via Next.
+func NewChunker(r io.Reader) *Chunker {
+	return &Chunker{br: bufio.NewReaderSize(r, MaxSize)}
+}
+
+// Next returns the next chunk, or io.EOF once the underlying reader is
+// exhausted.
+func (c *Chunker) Next() (Chunk, error) {
+	data, err := readOneChunk(c.br)
+	if len(data) == 0 {
+		return Chunk{}, err
+	}
+
+	chunk := Chunk{Offset: c.offset, Data: data}
+	c.offset += int64(len(data))
+
+	if err == io.EOF {
+		return chunk, nil
+	}
+	return chunk, err
+}
+
+// Split reads r to EOF and returns the chunk boundaries FastCDC would cut,
+// each capped between MinSize and MaxSize bytes, except the final chunk,
+// which holds whatever is left and may be shorter than MinSize. Callers that don't need
+// every chunk held in memory at once (e.g. to upload and discard them one
+// by one) should use Chunker directly instead.
+func Split(r io.Reader) ([]Chunk, error) {
+	chunker := NewChunker(r)
+
+	var chunks []Chunk
+	for {
+		chunk, err := chunker.Next()
+		if len(chunk.Data) > 0 {
+			chunks = append(chunks, chunk)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return chunks, nil
+}
+
+// readOneChunk consumes bytes from br until a FastCDC boundary is found (or
+// MaxSize is reached, or br is exhausted), returning the chunk's bytes.
+func readOneChunk(br *bufio.Reader) ([]byte, error) {
+	var buf []byte
+	var hash uint64
+
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return buf, err
+		}
+
+		buf = append(buf, b)
+		hash = (hash << 1) + gearTable[b]
+
+		if len(buf) >= MinSize && hash&boundaryMask == 0 {
+			return buf, nil
+		}
+		if len(buf) >= MaxSize {
+			return buf, nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package cdc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func randomData(size int, seed int64) []byte {
+	r := rand.New(rand.NewSource(seed))
+	data := make([]byte, size)
+	r.Read(data)
+	return data
+}
+
+func TestSplitReconstructsOriginalBytes(t *testing.T) {
+	data := randomData(10*MinSize, 1)
+
+	chunks, err := Split(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+
+	var got []byte
+	for _, c := range chunks {
+		got = append(got, c.Data...)
+	}
+
+	if !bytes.Equal(got, data) {
+		t.Fatalf("reassembled %d bytes, want %d, and/or content mismatch", len(got), len(data))
+	}
+}
+
+func TestAverageChunkSizeMatchesAvgSizeTuning(t *testing.T) {
+	// Generate enough data that the average should converge close to
+	// AvgSize; a wrong mask (e.g. off by one bit) skews this by ~2x.
+	data := randomData(16*AvgSize, 5)
+
+	chunks, err := Split(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+
+	// Exclude the final, possibly short, chunk from the average.
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks from %d bytes, got %d", len(data), len(chunks))
+	}
+	total := 0
+	for _, c := range chunks[:len(chunks)-1] {
+		total += len(c.Data)
+	}
+	avg := total / (len(chunks) - 1)
+
+	if avg < AvgSize/2 || avg > AvgSize*2 {
+		t.Errorf("average chunk size %d is not within 2x of AvgSize %d", avg, AvgSize)
+	}
+}
+
+func TestSplitChunkSizeBounds(t *testing.T) {
+	data := randomData(10*MinSize, 2)
+
+	chunks, err := Split(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+
+	for i, c := range chunks {
+		isLast := i == len(chunks)-1
+		if len(c.Data) > MaxSize {
+			t.Errorf("chunk %d has size %d, exceeding MaxSize %d", i, len(c.Data), MaxSize)
+		}
+		if !isLast && len(c.Data) < MinSize {
+			t.Errorf("non-final chunk %d has size %d, under MinSize %d", i, len(c.Data), MinSize)
+		}
+	}
+}
+
+func TestSplitIsDeterministic(t *testing.T) {
+	data := randomData(10*MinSize, 3)
+
+	chunksA, err := Split(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Split (a): %v", err)
+	}
+	chunksB, err := Split(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Split (b): %v", err)
+	}
+
+	if len(chunksA) != len(chunksB) {
+		t.Fatalf("got %d chunks then %d chunks for identical input", len(chunksA), len(chunksB))
+	}
+	for i := range chunksA {
+		if chunksA[i].Offset != chunksB[i].Offset || !bytes.Equal(chunksA[i].Data, chunksB[i].Data) {
+			t.Fatalf("chunk %d differs between identical runs", i)
+		}
+	}
+}
+
+func TestChunkerNextMatchesSplit(t *testing.T) {
+	data := randomData(10*MinSize, 6)
+
+	want, err := Split(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+
+	chunker := NewChunker(bytes.NewReader(data))
+	var got []Chunk
+	for {
+		chunk, err := chunker.Next()
+		if len(chunk.Data) > 0 {
+			got = append(got, chunk)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d chunks from Chunker, want %d from Split", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Offset != want[i].Offset || !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Fatalf("chunk %d differs between Chunker.Next and Split", i)
+		}
+	}
+}
+
+func TestChunkerNextNeverHoldsMoreThanOneChunk(t *testing.T) {
+	// A regression guard for the streaming contract: Next must not return
+	// data for more than one chunk per call, and must signal io.EOF with
+	// no data once the input is exhausted.
+	data := randomData(3*MinSize, 7)
+
+	chunker := NewChunker(bytes.NewReader(data))
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			if len(chunk.Data) != 0 {
+				t.Fatalf("expected no data alongside io.EOF, got %d bytes", len(chunk.Data))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if len(chunk.Data) > MaxSize {
+			t.Fatalf("chunk size %d exceeds MaxSize %d", len(chunk.Data), MaxSize)
+		}
+	}
+}
+
+func TestSplitShiftInsensitiveAwayFromEdit(t *testing.T) {
+	// An insertion in the middle of the data should only disturb the
+	// chunk(s) around the edit, not every chunk before or after it -
+	// that's the whole point of content-defined chunking over fixed-size
+	// blocks.
+	data := randomData(20*MinSize, 4)
+
+	edited := make([]byte, 0, len(data)+MinSize)
+	edited = append(edited, data[:10*MinSize]...)
+	edited = append(edited, randomData(MinSize, 99)...)
+	edited = append(edited, data[10*MinSize:]...)
+
+	before, err := Split(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Split(data): %v", err)
+	}
+	after, err := Split(bytes.NewReader(edited))
+	if err != nil {
+		t.Fatalf("Split(edited): %v", err)
+	}
+
+	chunkOids := func(chunks []Chunk) map[string]bool {
+		m := make(map[string]bool, len(chunks))
+		for _, c := range chunks {
+			sum := sha256.Sum256(c.Data)
+			m[hex.EncodeToString(sum[:])] = true
+		}
+		return m
+	}
+
+	beforeOids := chunkOids(before)
+	afterOids := chunkOids(after)
+
+	shared := 0
+	for oid := range beforeOids {
+		if afterOids[oid] {
+			shared++
+		}
+	}
+
+	if shared == 0 {
+		t.Fatalf("expected at least some chunks to survive an edit elsewhere in the data, got 0 shared out of %d", len(before))
+	}
+}
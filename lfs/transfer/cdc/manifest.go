@@ -0,0 +1,70 @@
+package cdc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ManifestSchemaVersion is bumped whenever the manifest format changes in a
+// way older clients can't parse. Old clients that don't understand chunked
+// manifests at all still recover the original object through WholeOid /
+// WholeSize, either by asking the server's gateway to reassemble it or, as
+// a last resort, re-requesting a full copy.
+const ManifestSchemaVersion = 1
+
+// ChunkRef identifies one chunk of a manifest by the SHA-256 of its
+// content, which doubles as its storage key on the server.
+type ChunkRef struct {
+	Oid    string `json:"oid"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// Manifest replaces the usual single-OID LFS pointer for an object
+// transferred through the cdc adapter. It records enough information to
+// reassemble the object from its chunks, plus the original whole-object
+// identity so a server-side gateway (or a client with no cdc support) can
+// still fetch it as a single blob.
+type Manifest struct {
+	Schema    int        `json:"schema"`
+	WholeOid  string     `json:"whole_oid"`
+	WholeSize int64      `json:"whole_size"`
+	Chunks    []ChunkRef `json:"chunks"`
+}
+
+// ChunkRefFor hashes a single chunk. It's split out from BuildManifest so a
+// caller streaming chunks one at a time (see Chunker) can build up a
+// Manifest's Chunks incrementally instead of holding every chunk's Data in
+// memory at once.
+func ChunkRefFor(c Chunk) ChunkRef {
+	sum := sha256.Sum256(c.Data)
+	return ChunkRef{
+		Oid:    hex.EncodeToString(sum[:]),
+		Offset: c.Offset,
+		Size:   int64(len(c.Data)),
+	}
+}
+
+// BuildManifest hashes each chunk and assembles the Manifest describing how
+// to reconstruct the object identified by (wholeOid, wholeSize) from them.
+func BuildManifest(wholeOid string, wholeSize int64, chunks []Chunk) *Manifest {
+	m := &Manifest{
+		Schema:    ManifestSchemaVersion,
+		WholeOid:  wholeOid,
+		WholeSize: wholeSize,
+		Chunks:    make([]ChunkRef, len(chunks)),
+	}
+
+	for i, c := range chunks {
+		m.Chunks[i] = ChunkRefFor(c)
+	}
+
+	return m
+}
+
+// Marshal serializes the manifest as JSON, so it can be stored and
+// transmitted as the object's content in place of the whole blob.
+func (m *Manifest) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
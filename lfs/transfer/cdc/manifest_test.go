@@ -0,0 +1,64 @@
+package cdc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBuildManifestHashesAndOffsets(t *testing.T) {
+	chunks := []Chunk{
+		{Offset: 0, Data: []byte("hello ")},
+		{Offset: 6, Data: []byte("world")},
+	}
+
+	m := BuildManifest("whole-oid", 11, chunks)
+
+	if m.Schema != ManifestSchemaVersion {
+		t.Errorf("Schema = %d, want %d", m.Schema, ManifestSchemaVersion)
+	}
+	if m.WholeOid != "whole-oid" || m.WholeSize != 11 {
+		t.Errorf("whole object identity not preserved: %+v", m)
+	}
+	if len(m.Chunks) != len(chunks) {
+		t.Fatalf("got %d chunk refs, want %d", len(m.Chunks), len(chunks))
+	}
+
+	for i, c := range chunks {
+		want := sha256.Sum256(c.Data)
+		wantHex := hex.EncodeToString(want[:])
+
+		ref := m.Chunks[i]
+		if ref.Oid != wantHex {
+			t.Errorf("chunk %d oid = %s, want %s", i, ref.Oid, wantHex)
+		}
+		if ref.Offset != c.Offset {
+			t.Errorf("chunk %d offset = %d, want %d", i, ref.Offset, c.Offset)
+		}
+		if ref.Size != int64(len(c.Data)) {
+			t.Errorf("chunk %d size = %d, want %d", i, ref.Size, len(c.Data))
+		}
+	}
+}
+
+func TestManifestMarshalRoundTrips(t *testing.T) {
+	m := BuildManifest("whole-oid", 11, []Chunk{{Offset: 0, Data: []byte("data")}})
+
+	body, err := m.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if len(body) == 0 {
+		t.Fatal("Marshal produced empty body")
+	}
+
+	var decoded Manifest
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, m) {
+		t.Errorf("round-trip = %+v, want %+v", decoded, *m)
+	}
+}
@@ -0,0 +1,309 @@
+package lfs
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/github/git-lfs/lfs/events"
+)
+
+// chunkedUploadThreshold is the object size above which uploads are split
+// into multiple parts so that a checkpoint can be taken between them.
+const chunkedUploadThreshold = 20 * 1024 * 1024 // 20 MiB
+
+// chunkSize is the size of each part of a chunked/multipart upload.
+const chunkSize = 8 * 1024 * 1024 // 8 MiB
+
+// UploadCheckpoint records how far a single object's upload had gotten the
+// last time this queue ran, so a later invocation can pick up where it left
+// off instead of re-sending bytes that already made it to the server.
+type UploadCheckpoint struct {
+	Oid       string         `json:"oid"`
+	Size      int64          `json:"size"`
+	Offset    int64          `json:"offset"`
+	ETags     map[int]string `json:"etags,omitempty"`
+	Completed bool           `json:"completed"`
+}
+
+// uploadQueueState is the on-disk shape of a checkpoint file.
+type uploadQueueState struct {
+	Remote  string                       `json:"remote"`
+	Objects map[string]*UploadCheckpoint `json:"objects"`
+}
+
+// TransferAdapter lets an alternate transfer strategy — e.g. the content-
+// defined-chunking delta transfer in lfs/transfer/cdc — handle an upload in
+// place of the UploadQueue's own whole-object/multipart path. Adapters are
+// tried in registration order; the first one whose Applicable returns true
+// for a given object takes over that object's upload.
+type TransferAdapter interface {
+	// Applicable reports whether this adapter should handle u instead of
+	// the default upload path.
+	Applicable(u *Uploadable) bool
+	// Upload transfers u using this adapter's own transport.
+	Upload(u *Uploadable) *WrappedError
+}
+
+// UploadQueue manages uploading a set of files to the LFS API. Unlike a
+// plain worker pool, it checkpoints progress to disk as it goes, so a push
+// interrupted by a network failure or Ctrl-C can resume from where it
+// stopped instead of starting over.
+type UploadQueue struct {
+	DryRun       bool
+	workers      int
+	bandwidthCap int64 // bytes/sec per connection, 0 = unlimited
+
+	statePath string
+	state     *uploadQueueState
+
+	uploadc chan *Uploadable
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	errors   []*WrappedError
+	finished int64
+}
+
+// NewUploadQueue builds an UploadQueue sized and tuned from lfs.Config
+// (worker count, bandwidth cap, resume behavior), loading any checkpoint
+// left over from a previous, interrupted run against the current remote.
+func NewUploadQueue(files int, size int64, dryRun bool) *UploadQueue {
+	workers := Config.UploadWorkers
+	if workers <= 0 {
+		workers = 3
+	}
+	if workers > files && files > 0 {
+		workers = files
+	}
+
+	q := &UploadQueue{
+		DryRun:       dryRun,
+		workers:      workers,
+		bandwidthCap: Config.UploadBandwidthCap,
+		statePath:    checkpointPath(Config.CurrentRemote),
+		uploadc:      make(chan *Uploadable, files),
+	}
+
+	q.state = q.loadState()
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return q
+}
+
+func checkpointPath(remote string) string {
+	if remote == "" {
+		remote = "origin"
+	}
+	return filepath.Join(Config.LocalGitDir(), "lfs", "state", "pre-push-"+remote+".json")
+}
+
+func (q *UploadQueue) loadState() *uploadQueueState {
+	state := &uploadQueueState{Remote: Config.CurrentRemote, Objects: make(map[string]*UploadCheckpoint)}
+
+	if Config.UploadFresh {
+		os.Remove(q.statePath)
+		return state
+	}
+
+	data, err := ioutil.ReadFile(q.statePath)
+	if err != nil {
+		return state
+	}
+
+	var onDisk uploadQueueState
+	if err := json.Unmarshal(data, &onDisk); err != nil || onDisk.Remote != Config.CurrentRemote {
+		return state
+	}
+
+	if onDisk.Objects != nil {
+		state.Objects = onDisk.Objects
+	}
+	return state
+}
+
+func (q *UploadQueue) persistState() {
+	os.MkdirAll(filepath.Dir(q.statePath), 0755)
+
+	q.mu.Lock()
+	data, err := json.Marshal(q.state)
+	q.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	ioutil.WriteFile(q.statePath, data, 0644)
+}
+
+// Add queues a file for upload. Objects whose checkpoint is already marked
+// completed for the same size are skipped entirely.
+func (q *UploadQueue) Add(u *Uploadable) {
+	q.mu.Lock()
+	cp, ok := q.state.Objects[u.Oid]
+	q.mu.Unlock()
+
+	if ok && cp.Completed && cp.Size == u.Size {
+		return
+	}
+
+	q.uploadc <- u
+}
+
+func (q *UploadQueue) worker() {
+	defer q.wg.Done()
+
+	for u := range q.uploadc {
+		var err *WrappedError
+		if adapter := q.adapterFor(u); adapter != nil {
+			err = adapter.Upload(u)
+		} else if u.Size >= chunkedUploadThreshold {
+			err = q.uploadChunked(u)
+		} else {
+			err = q.uploadWhole(u)
+		}
+
+		if err != nil {
+			q.mu.Lock()
+			q.errors = append(q.errors, err)
+			q.mu.Unlock()
+			Config.Events.Publish(&events.Event{
+				Type: events.TypeError, Oid: u.Oid, Name: u.Filename, Message: err.Error(),
+			})
+			continue
+		}
+
+		q.mu.Lock()
+		q.state.Objects[u.Oid] = &UploadCheckpoint{Oid: u.Oid, Size: u.Size, Completed: true}
+		q.mu.Unlock()
+		q.persistState()
+
+		Config.Events.Publish(&events.Event{
+			Type: events.TypeComplete, Oid: u.Oid, Name: u.Filename, Size: u.Size,
+		})
+	}
+}
+
+// adapterFor returns the first registered TransferAdapter willing to handle
+// u, or nil if none of them claim it and the default upload path should be
+// used instead.
+func (q *UploadQueue) adapterFor(u *Uploadable) TransferAdapter {
+	for _, adapter := range Config.TransferAdapters {
+		if adapter.Applicable(u) {
+			return adapter
+		}
+	}
+	return nil
+}
+
+// uploadWhole uploads an object in a single request, exactly as the
+// pre-redesign queue did.
+func (q *UploadQueue) uploadWhole(u *Uploadable) *WrappedError {
+	reader, err := u.Reader()
+	if err != nil {
+		return Error(err)
+	}
+	defer reader.Close()
+
+	return u.Upload(q.rateLimited(reader))
+}
+
+// uploadChunked splits a large object into chunkSize parts and uploads each
+// one in turn, checkpointing the byte offset after every part so a resumed
+// run can seek straight to the first unsent chunk.
+func (q *UploadQueue) uploadChunked(u *Uploadable) *WrappedError {
+	q.mu.Lock()
+	cp, ok := q.state.Objects[u.Oid]
+	if !ok {
+		cp = &UploadCheckpoint{Oid: u.Oid, Size: u.Size, ETags: make(map[int]string)}
+		q.state.Objects[u.Oid] = cp
+	}
+	if cp.ETags == nil {
+		cp.ETags = make(map[int]string)
+	}
+	q.mu.Unlock()
+
+	upload, wErr := u.BeginMultipart(cp.Offset)
+	if wErr != nil {
+		return wErr
+	}
+
+	for offset := cp.Offset; offset < u.Size; offset += chunkSize {
+		partLen := int64(chunkSize)
+		if remaining := u.Size - offset; remaining < partLen {
+			partLen = remaining
+		}
+
+		part, err := u.ReaderAt(offset, partLen)
+		if err != nil {
+			return Error(err)
+		}
+
+		etag, wErr := upload.UploadPart(q.rateLimited(part), offset, partLen)
+		part.Close()
+		if wErr != nil {
+			return wErr
+		}
+
+		q.mu.Lock()
+		cp.Offset = offset + partLen
+		cp.ETags[int(offset/chunkSize)] = etag
+		q.state.Objects[u.Oid] = cp
+		q.mu.Unlock()
+		q.persistState()
+
+		Config.Events.Publish(&events.Event{
+			Type: events.TypeProgress, Oid: u.Oid, Name: u.Filename, Size: u.Size, Bytes: cp.Offset,
+		})
+	}
+
+	return upload.Complete(cp.ETags)
+}
+
+// rateLimited wraps r so reads never exceed the configured per-connection
+// bandwidth cap. With no cap configured, r is returned unchanged.
+func (q *UploadQueue) rateLimited(r io.Reader) io.Reader {
+	if q.bandwidthCap <= 0 {
+		return r
+	}
+	return newRateLimitedReader(r, q.bandwidthCap)
+}
+
+// Wait blocks until every queued upload has finished (successfully or not)
+// and flushes the final checkpoint state to disk. Once every object is
+// marked completed, the checkpoint file is removed so the next push starts
+// clean.
+func (q *UploadQueue) Wait() {
+	close(q.uploadc)
+	q.wg.Wait()
+
+	q.mu.Lock()
+	allDone := len(q.errors) == 0
+	for _, cp := range q.state.Objects {
+		if !cp.Completed {
+			allDone = false
+			break
+		}
+	}
+	q.mu.Unlock()
+
+	if allDone {
+		os.Remove(q.statePath)
+		return
+	}
+
+	q.persistState()
+}
+
+// Errors returns every error encountered while uploading.
+func (q *UploadQueue) Errors() []*WrappedError {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.errors
+}
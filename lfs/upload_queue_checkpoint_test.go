@@ -0,0 +1,50 @@
+package lfs
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// TestUploadQueueCheckpointConcurrentAccess exercises the same access
+// pattern as uploadChunked/persistState: one goroutine mutating a
+// checkpoint's ETags map while another marshals the whole state, both
+// serialized through UploadQueue.mu. Run with -race to catch a regression
+// of the concurrent map read/write this guards against.
+func TestUploadQueueCheckpointConcurrentAccess(t *testing.T) {
+	q := &UploadQueue{state: &uploadQueueState{
+		Remote:  "origin",
+		Objects: map[string]*UploadCheckpoint{},
+	}}
+
+	q.mu.Lock()
+	cp := &UploadCheckpoint{Oid: "abc", Size: 100, ETags: map[int]string{}}
+	q.state.Objects["abc"] = cp
+	q.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			q.mu.Lock()
+			cp.Offset = int64(i)
+			cp.ETags[i] = "etag"
+			q.mu.Unlock()
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			q.mu.Lock()
+			if _, err := json.Marshal(q.state); err != nil {
+				t.Errorf("Marshal: %v", err)
+			}
+			q.mu.Unlock()
+		}
+	}()
+
+	wg.Wait()
+}